@@ -0,0 +1,30 @@
+package main
+
+// Backend abstracts the platform audio output API so Context does not need
+// to depend on any one sound library directly. newDefaultBackend (implemented
+// once per build tag in backend_*.go) picks the concrete implementation.
+type Backend interface {
+	// Open prepares the device for playback at the given sample rate,
+	// channel count, and sample format (one of the Fmt* constants below).
+	Open(sampleRate, channels, format int) error
+	// Write pushes a buffer of raw PCM16 samples to the device, blocking
+	// until the backend is ready to accept them.
+	Write(buf []byte) error
+	// Close releases the device.
+	Close() error
+}
+
+const (
+	FmtMono16 = iota
+	FmtStereo16
+)
+
+// newBackend opens and returns the Backend selected for this build (see the
+// build tags on backend_openal.go, backend_oto.go, and backend_portaudio.go).
+func newBackend(sampleRate, channels, format int) (Backend, error) {
+	b := newDefaultBackend()
+	if err := b.Open(sampleRate, channels, format); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
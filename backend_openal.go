@@ -0,0 +1,64 @@
+//go:build openal
+
+package main
+
+import (
+	"log"
+
+	"golang.org/x/mobile/exp/audio/al"
+)
+
+// openalBackend is the original OpenAL implementation, kept behind the
+// "openal" build tag for platforms where CGO and an OpenAL device are
+// available. It owns the buffer ring that used to live directly on Context.
+type openalBackend struct {
+	source al.Source
+	queue  []al.Buffer
+	format uint32
+}
+
+func newDefaultBackend() Backend {
+	return &openalBackend{}
+}
+
+func (b *openalBackend) Open(sampleRate, channels, format int) error {
+	if err := al.OpenDevice(); err != nil {
+		return err
+	}
+	b.source = al.GenSources(1)[0]
+	if format == FmtStereo16 {
+		b.format = al.FormatStereo16
+	} else {
+		b.format = al.FormatMono16
+	}
+	return nil
+}
+
+func (b *openalBackend) Write(buf []byte) error {
+	n := b.source.BuffersProcessed()
+	if n > 0 {
+		rm := b.queue[:n]
+		b.queue = b.queue[n:]
+		b.source.UnqueueBuffers(rm...)
+		al.DeleteBuffers(rm...)
+	}
+	gb := al.GenBuffers(1)
+	gb[0].BufferData(b.format, buf, SampleRate)
+	b.source.QueueBuffers(gb...)
+	b.queue = append(b.queue, gb...)
+
+	if len(b.queue) >= QUEUE {
+		log.Println("openal: buffer queue saturated, dropping oldest")
+		old := b.queue[0]
+		b.queue = b.queue[1:]
+		b.source.UnqueueBuffers(old)
+		al.DeleteBuffers(old)
+	}
+	al.PlaySources(b.source)
+	return nil
+}
+
+func (b *openalBackend) Close() error {
+	al.StopSources(b.source)
+	return nil
+}
@@ -0,0 +1,55 @@
+//go:build !openal && !portaudio
+
+package main
+
+import (
+	"io"
+	"time"
+
+	"github.com/ebitengine/oto/v3"
+)
+
+// otoBackend is the default backend: a pure-Go player via oto, so the module
+// runs with CGO_ENABLED=0 and without an OpenAL device installed. oto's
+// Player pulls samples from an io.Reader, so Write feeds it through an
+// io.Pipe: the pipe's blocking Write is what gives us the same
+// write-blocks-until-consumed backpressure Context.Play expects.
+type otoBackend struct {
+	w      io.WriteCloser
+	player *oto.Player
+}
+
+func newDefaultBackend() Backend {
+	return &otoBackend{}
+}
+
+func (b *otoBackend) Open(sampleRate, channels, format int) error {
+	ctx, ready, err := oto.NewContext(&oto.NewContextOptions{
+		SampleRate:   sampleRate,
+		ChannelCount: channels,
+		Format:       oto.FormatSignedInt16LE,
+		BufferSize:   50 * time.Millisecond,
+	})
+	if err != nil {
+		return err
+	}
+	<-ready
+
+	r, w := io.Pipe()
+	b.w = w
+	b.player = ctx.NewPlayer(r)
+	b.player.Play()
+	return nil
+}
+
+func (b *otoBackend) Write(buf []byte) error {
+	_, err := b.w.Write(buf)
+	return err
+}
+
+func (b *otoBackend) Close() error {
+	if err := b.w.Close(); err != nil {
+		return err
+	}
+	return b.player.Close()
+}
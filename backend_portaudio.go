@@ -0,0 +1,54 @@
+//go:build portaudio
+
+package main
+
+import (
+	"encoding/binary"
+
+	"github.com/gordonklaus/portaudio"
+)
+
+// portaudioBackend routes output through PortAudio, for platforms where
+// neither OpenAL nor oto's target device is the right fit.
+type portaudioBackend struct {
+	stream *portaudio.Stream
+	out    []int16
+}
+
+func newDefaultBackend() Backend {
+	return &portaudioBackend{}
+}
+
+func (b *portaudioBackend) Open(sampleRate, channels, format int) error {
+	if err := portaudio.Initialize(); err != nil {
+		return err
+	}
+	b.out = make([]int16, 1024)
+	stream, err := portaudio.OpenDefaultStream(0, channels, float64(sampleRate), len(b.out), &b.out)
+	if err != nil {
+		return err
+	}
+	if err := stream.Start(); err != nil {
+		return err
+	}
+	b.stream = stream
+	return nil
+}
+
+func (b *portaudioBackend) Write(buf []byte) error {
+	n := len(buf) / 2
+	if n != len(b.out) {
+		b.out = make([]int16, n)
+	}
+	for i := 0; i < n; i++ {
+		b.out[i] = int16(binary.LittleEndian.Uint16(buf[i*2 : i*2+2]))
+	}
+	return b.stream.Write()
+}
+
+func (b *portaudioBackend) Close() error {
+	if err := b.stream.Stop(); err != nil {
+		return err
+	}
+	return portaudio.Terminate()
+}
@@ -1,15 +1,24 @@
 package main
 
 import (
+	"context"
 	"encoding/binary"
-	"fmt"
+	"flag"
 	"log"
 	"math"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
 	"time"
 
-	"golang.org/x/mobile/exp/audio/al"
-	"golang.org/x/mobile/exp/f32"
+	"github.com/sioncojp/play-getwild/midi"
+)
+
+var (
+	outFile  = flag.String("o", "", "render the score to this WAV file instead of playing it live")
+	midiPort = flag.String("midi", "", "connect to this MIDI input port and play live instead of the built-in score")
+	smfFile  = flag.String("smf", "", "play this Standard MIDI File instead of the built-in score")
 )
 
 var pctx *Context
@@ -17,221 +26,191 @@ var pianoPlayer *Piano
 
 const (
 	Pi         = float32(math.Pi)
-	Fmt        = al.FormatStereo16
 	QUEUE      = 500
 	SampleRate = 10000 // 音の高さのベース
 )
 
 type Oscillator func() float32
 
+// Context drives a Backend from an Oscillator: Play pulls samples, encodes
+// them to PCM16, and hands them to whichever Backend NewContext selected.
 type Context struct {
 	sync.RWMutex
-	source     al.Source
-	queue      []al.Buffer
-	oscillator Oscillator
-}
-
-type Piano struct {
-	notes      []bool
+	backend    Backend
 	oscillator Oscillator
 }
 
-func G(gain float32, f Oscillator) Oscillator {
-	return func() float32 {
-		return gain * f()
-	}
-}
-
-func GenOscillator(freq float32) Oscillator {
-	dt := 1.0 / float32(SampleRate)
-	k := 2.0 * Pi * freq
-	T := 1.0 / freq
-	t := float32(0.0)
-	return func() float32 {
-		res := f32.Sin(k * t)
-		t += dt
-		if t > T {
-			t -= T
-		}
-		return res
-	}
-}
-
-func Multiplex(fs ...Oscillator) Oscillator {
-	return func() float32 {
-		res := float32(0)
-		for _, osc := range fs {
-			res += osc()
-		}
-		return res
-	}
-}
-
-func GenEnvelope(press *bool, f Oscillator) Oscillator {
-	dt := 1.0 / float32(SampleRate)
-	top := false
-	gain := float32(0.0)
-	attackd := dt / 0.01
-	dekeyd := dt / 0.03
-	sustainlevel := float32(0.3)
-	sustaind := dt / 7.0
-	released := dt / 0.8
-	return func() float32 {
-		if *press {
-			if !top {
-				gain += attackd
-				if gain > 1.0 {
-					top = true
-					gain = 1.0
-				}
-			} else {
-				if gain > sustainlevel {
-					gain -= dekeyd
-				} else {
-					gain -= sustaind
-				}
-				if gain < 0.0 {
-					gain = 0.0
-				}
-			}
-		} else {
-			top = false
-			gain -= released
-			if gain < 0.0 {
-				gain = 0.0
-			}
-		}
-		return gain * f()
-	}
-}
-
 func NewContext(oscillator Oscillator) *Context {
-	if err := al.OpenDevice(); err != nil {
+	b, err := newBackend(SampleRate, 1, FmtMono16)
+	if err != nil {
 		log.Fatal(err)
 	}
-	s := al.GenSources(1)
 	return &Context{
-		source:     s[0],
-		queue:      []al.Buffer{},
+		backend:    b,
 		oscillator: oscillator,
 	}
 }
 
-func NewPiano(freqs []float32) *Piano {
-	p := new(Piano)
-	p.notes = make([]bool, len(freqs))
-	envelopes := []Oscillator{}
-	for i, f := range freqs {
-		base := []Oscillator{}
-		for j := float32(1.0); j <= 8; j++ {
-			base = append(base, G(0.5/j, GenOscillator(f*j)))
-		}
-		base = append(base, G(0.3, GenOscillator(f+2)))
-		osc := Multiplex(base...)
-		envelopes = append(envelopes, G(0.4, GenEnvelope(&p.notes[i], osc)))
-	}
-	p.oscillator = Multiplex(envelopes...) // all note oscilator multiplex
-	return p
-}
-func (p *Piano) NoteOn(key int) {
-	p.notes[key] = true
-}
-
-func (p *Piano) NoteOff(key int) {
-	p.notes[key] = false
-}
-
-func (p *Piano) GetOscillator() Oscillator { return p.oscillator }
-
 func (c *Context) Play(q int) {
 	c.Lock()
 	defer c.Unlock()
-	n := c.source.BuffersProcessed()
-	if n > 0 {
-		rm := c.queue[:n]
-		c.queue = nil
-		c.source.UnqueueBuffers(rm...)
-		al.DeleteBuffers(rm...)
-	}
-	fmt.Println(len(c.queue))
-	for len(c.queue) < QUEUE {
-		b := al.GenBuffers(q) // 音の長さ
+	for i := 0; i < q; i++ {
 		buf := make([]byte, 2048)
 		for n := 0; n < 2048; n += 2 {
 			f := c.oscillator()
-			v := int16(float32(92767) * f) // 音の大きさ
+			v := int16(float32(32767) * f) // 音の大きさ
 			binary.LittleEndian.PutUint16(buf[n:n+2], uint16(v))
 		}
-		b[0].BufferData(Fmt, buf, SampleRate)
-		c.source.QueueBuffers(b...)
-		c.queue = append(c.queue, b...)
+		if err := c.backend.Write(buf); err != nil {
+			log.Println(err)
+			return
+		}
 	}
-	al.PlaySources(c.source)
 }
 
 func (c *Context) Close() {
 	c.Lock()
 	defer c.Unlock()
-	al.StopSources(c.source)
+	c.backend.Close()
 }
 
-func PlaySound(s, q int, slp time.Duration) {
-	pianoPlayer.NoteOn(s)
-	pctx.Play(q)
-	time.Sleep(slp * time.Millisecond)
+// PlaySound is a thin wrapper around Sequencer for callers that just want to
+// play a single note, preserved from before Sequencer existed: q is the
+// number of audio chunks the note rings for (as pctx.Play(q) used to take
+// directly), and slp is how much longer to hold it before cutting off.
+func PlaySound(key, q int, slp time.Duration) {
+	const chunk = 2048 / 2 // samples per Context.Play(1) call, matching Sequencer.Play
+	tick := time.Second * chunk / SampleRate
+	noteDur := time.Duration(q)*tick + slp*time.Millisecond
+
+	seq := NewSequencer(pianoPlayer, pctx)
+	seq.ScheduleNoteOn(key, 0)
+	seq.ScheduleNoteOff(key, noteDur)
+
+	ctx, cancel := context.WithTimeout(context.Background(), noteDur+500*time.Millisecond)
+	defer cancel()
+	seq.Play(ctx)
 	pctx.Close()
-	pianoPlayer.NoteOff(s)
+}
+
+// getwildTempo is the tempo getwildPattern is quantized to: one Pattern step
+// is a sixteenth note, 100ms at this bpm.
+const getwildTempo = 150
+
+// getwildPattern is the Getwild melody from the original PlaySound/
+// time.Sleep call chain, expressed as a single-track Pattern instead of
+// imperative NoteOn/NoteOff calls: each row below is one melodic phrase, -1
+// is a rest, and a run of repeated keys holds that note for the run's
+// length.
+var getwildPattern = Pattern{
+	Tracks: [][]int{
+		{
+			6, 6, 6, 6, 6, 4, 4, 4, 4, 4, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, -1,
+			6, 6, 6, 4, 4, 4, 4, 4, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2,
+			2, 2, 2, 4, 4, 4, 6, 6, 6, 6, 6, 6, 6, 6, 6, -1, -1, 7, 7, 7, 6, 6, 6, 2, 2, 2, 2, 2, 2, 6, 6, 6,
+			6, 6, 6, 4, 4, 4, 4, 4, 4, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2,
+		},
+	},
+}
+
+// scheduleSMF expands a decoded Standard MIDI File onto seq, mapping each
+// event's MIDI note number onto piano's key range and dropping any note
+// outside it. It returns the offset of the last scheduled event.
+func scheduleSMF(seq *Sequencer, mseq *midi.Sequence, piano *Piano) time.Duration {
+	var last time.Duration
+	for _, e := range mseq.Events {
+		key, ok := piano.Key(e.Note)
+		if !ok {
+			continue
+		}
+		if e.On {
+			seq.ScheduleNoteOn(key, e.At)
+		} else {
+			seq.ScheduleNoteOff(key, e.At)
+		}
+		if e.At > last {
+			last = e.At
+		}
+	}
+	return last
 }
 
 func main() {
-	pianoPlayer = NewPiano([]float32{
-		246.941650628,
-		261.625565301,
-		277.182630977,
-		293.664767917,
-		311.126983722,
-		329.627556913,
-		349.228231433,
-		369.994422712,
-		391.995435982,
-		415.30469758,
-		440.0,
-		466.163761518,
-		493.883301256,
-		523.251130601,
-	})
+	flag.Parse()
+
+	// B3(59) .. C5(72), the range the Getwild score below needs.
+	pianoPlayer = NewPiano(59, 72, DefaultADSR, Sine, StandardTuning)
+
+	if *midiPort != "" {
+		pctx = NewContext(pianoPlayer.GetOscillator())
+		defer pctx.Close()
+
+		events, closer, err := midi.OpenInput(*midiPort)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer closer.Close()
+
+		go func() {
+			for {
+				pctx.Play(1)
+			}
+		}()
+
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+
+		for {
+			select {
+			case e := <-events:
+				key, ok := pianoPlayer.Key(e.Note)
+				if !ok {
+					continue
+				}
+				if e.On {
+					pianoPlayer.NoteOn(key)
+				} else {
+					pianoPlayer.NoteOff(key)
+				}
+			case <-sig:
+				return
+			}
+		}
+		return
+	}
+
+	seq := NewSequencer(pianoPlayer, nil)
+	var tail time.Duration
+	if *smfFile != "" {
+		mseq, err := midi.LoadSMF(*smfFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		tail = scheduleSMF(seq, mseq, pianoPlayer) + 500*time.Millisecond
+	} else {
+		seq.SetTempo(getwildTempo)
+		seq.Schedule(getwildPattern)
+		tail = time.Duration(len(getwildPattern.Tracks[0]))*seq.stepDuration() + 500*time.Millisecond
+	}
+
+	if *outFile != "" {
+		f, err := os.Create(*outFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+
+		if err := NewRenderer(seq.EventOscillator()).Render(f, tail); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
 	pctx = NewContext(pianoPlayer.GetOscillator())
+	seq.ctx = pctx
 
-	PlaySound(6, 70, 500)
-	PlaySound(4, 70, 500)
-	PlaySound(2, 10, 1000)
-
-	time.Sleep(100 * time.Millisecond)
-
-	PlaySound(6, 100, 300)
-	PlaySound(4, 80, 500)
-	PlaySound(2, 80, 500)
-	PlaySound(2, 100, 300)
-	PlaySound(2, 10, 1000)
-
-	time.Sleep(200 * time.Millisecond)
-
-	PlaySound(2, 100, 300)
-	PlaySound(4, 150, 300)
-	PlaySound(6, 150, 300)
-	PlaySound(6, 150, 300)
-	PlaySound(6, 150, 300)
-	PlaySound(7, 150, 300)
-	PlaySound(6, 150, 300)
-	PlaySound(2, 150, 300)
-	PlaySound(2, 150, 300)
-	PlaySound(6, 150, 300)
-
-	time.Sleep(10 * time.Millisecond)
-
-	PlaySound(6, 80, 300)
-	PlaySound(4, 50, 600)
-	PlaySound(2, 200, 180)
-	PlaySound(2, 10, 1000)
+	ctx, cancel := context.WithTimeout(context.Background(), tail)
+	defer cancel()
+	seq.Play(ctx)
+	pctx.Close()
 }
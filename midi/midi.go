@@ -0,0 +1,144 @@
+// Package midi bridges live MIDI input and Standard MIDI Files into plain
+// note events. It knows nothing about Piano or Sequencer so it can be
+// imported without pulling in the audio engine; the caller maps Event.Note
+// (a MIDI note number) onto its own tuning.
+package midi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"gitlab.com/gomidi/midi"
+	"gitlab.com/gomidi/midi/reader"
+	"gitlab.com/gomidi/midi/smf"
+	"gitlab.com/gomidi/rtmididrv"
+)
+
+// Event is a single NoteOn/NoteOff crossing the MIDI boundary. Note is a
+// MIDI note number (0-127). At is only meaningful for events read from a
+// Sequence; events streamed from OpenInput fire as they arrive.
+type Event struct {
+	Note int
+	On   bool
+	At   time.Duration
+}
+
+// OpenInput opens the named MIDI input port and streams its note-on/off
+// messages as Events on the returned channel. The caller must Close the
+// returned io.Closer (e.g. with defer) to release the port and driver and
+// stop the stream; the Events channel is never closed.
+func OpenInput(portName string) (<-chan Event, io.Closer, error) {
+	drv, err := rtmididrv.New()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ins, err := drv.Ins()
+	if err != nil {
+		drv.Close()
+		return nil, nil, err
+	}
+
+	var in midi.In
+	for _, p := range ins {
+		if p.String() == portName {
+			in = p
+			break
+		}
+	}
+	if in == nil {
+		drv.Close()
+		return nil, nil, fmt.Errorf("midi: no input port named %q", portName)
+	}
+	if err := in.Open(); err != nil {
+		drv.Close()
+		return nil, nil, err
+	}
+
+	events := make(chan Event, 64)
+	rd := reader.New(
+		reader.NoLogger(),
+		reader.NoteOn(func(p *reader.Position, channel, key, velocity uint8) {
+			events <- Event{Note: int(key), On: true}
+		}),
+		reader.NoteOff(func(p *reader.Position, channel, key, velocity uint8) {
+			events <- Event{Note: int(key), On: false}
+		}),
+	)
+	if err := reader.ListenTo(rd, in); err != nil {
+		in.Close()
+		drv.Close()
+		return nil, nil, err
+	}
+
+	return events, &inputCloser{in: in, drv: drv}, nil
+}
+
+// inputCloser releases the port opened by OpenInput and then its driver, so
+// callers get a single Close that tears down both.
+type inputCloser struct {
+	in  midi.In
+	drv midi.Driver
+}
+
+func (c *inputCloser) Close() error {
+	inErr := c.in.Close()
+	drvErr := c.drv.Close()
+	return errors.Join(inErr, drvErr)
+}
+
+// Sequence is a Standard MIDI File decoded into note events, timestamped
+// from the start of the file.
+type Sequence struct {
+	Events []Event
+}
+
+// defaultBPM is used until a tempo meta event says otherwise.
+const defaultBPM = 120.0
+
+// LoadSMF reads a Standard MIDI File from path and returns its note-on/off
+// events, converted from ticks to wall-clock time using the file's time
+// division and tempo (defaultBPM until a tempo meta event changes it).
+func LoadSMF(path string) (*Sequence, error) {
+	hdr, err := reader.ReadSMFFileHeader(path)
+	if err != nil {
+		return nil, err
+	}
+	division, ok := hdr.TimeFormat.(smf.MetricTicks)
+	if !ok {
+		return nil, fmt.Errorf("midi: %s does not use metric (ticks-per-quarter-note) timing", path)
+	}
+
+	seq := &Sequence{}
+	bpm := float64(defaultBPM)
+	var elapsed time.Duration
+
+	// advance accumulates the wall-clock time for p's DeltaTicks at the
+	// tempo in effect since the previous message, so a mid-file tempo
+	// change only affects ticks that come after it.
+	advance := func(p *reader.Position) time.Duration {
+		elapsed += division.FractionalDuration(bpm, p.DeltaTicks)
+		return elapsed
+	}
+
+	rd := reader.New(
+		reader.NoLogger(),
+		reader.TempoBPM(func(p reader.Position, newBPM float64) {
+			elapsed += division.FractionalDuration(bpm, p.DeltaTicks)
+			bpm = newBPM
+		}),
+		reader.NoteOn(func(p *reader.Position, channel, key, velocity uint8) {
+			seq.Events = append(seq.Events, Event{Note: int(key), On: true, At: advance(p)})
+		}),
+		reader.NoteOff(func(p *reader.Position, channel, key, velocity uint8) {
+			seq.Events = append(seq.Events, Event{Note: int(key), On: false, At: advance(p)})
+		}),
+	)
+
+	if err := reader.ReadSMFFile(rd, path); err != nil {
+		return nil, err
+	}
+	return seq, nil
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/mobile/exp/f32"
+)
+
+// Waveform selects the shape GenOscillator produces.
+type Waveform int
+
+const (
+	Sine Waveform = iota
+	Square
+	Triangle
+	Saw
+	Noise
+)
+
+// ADSR holds the per-voice envelope timings. Attack/Decay/Release are the
+// durations of their ramps; Sustain is the level (0.0-1.0) that Decay ramps
+// down to. While the key stays pressed, the gain then keeps fading slowly
+// below that level (at 1/7 of full scale per second) until Release.
+type ADSR struct {
+	Attack  time.Duration
+	Decay   time.Duration
+	Sustain float32
+	Release time.Duration
+}
+
+// DefaultADSR mirrors the envelope constants the piano used before ADSR
+// became configurable.
+var DefaultADSR = ADSR{
+	Attack:  10 * time.Millisecond,
+	Decay:   30 * time.Millisecond,
+	Sustain: 0.3,
+	Release: 800 * time.Millisecond,
+}
+
+// EqualTemperament derives note frequencies from a reference A4 pitch using
+// 12-tone equal temperament: freq = A4 * 2^((n-69)/12), where n is the MIDI
+// note number (A4 = 69).
+type EqualTemperament struct {
+	A4 float32
+}
+
+// StandardTuning is A4 = 440Hz, the tuning the piano used to hard-code.
+var StandardTuning = EqualTemperament{A4: 440.0}
+
+// Freq returns the frequency of MIDI note number n under this tuning.
+func (et EqualTemperament) Freq(n int) float32 {
+	return et.A4 * float32(math.Pow(2, float64(n-69)/12.0))
+}
+
+// DefaultPolyphony is the voice count NewPiano starts with.
+const DefaultPolyphony = 16
+
+// voice is one slot in the piano's polyphony pool. key is -1 while the
+// voice has never been used; once assigned it stays set (even after the
+// note releases) so NoteOn can find the least-recently-triggered voice to
+// steal.
+type voice struct {
+	key   int
+	press *bool
+	env   Oscillator
+	gen   int64
+}
+
+// Piano is a bank of Voices sharing a tuning, waveform-per-key table, and
+// ADSR, mixed down through a soft limiter so chords can't clip. mu guards
+// waveforms and every field below it, since NoteOn/NoteOff typically run on a different
+// goroutine than the one driving GetOscillator's returned closure (e.g. a
+// MIDI input loop alongside the audio callback).
+type Piano struct {
+	loNote    int
+	freqs     []float32
+	waveforms []Waveform
+
+	mu         sync.Mutex
+	adsr       ADSR
+	voices     []*voice
+	masterGain float32
+	gen        int64
+}
+
+func G(gain float32, f Oscillator) Oscillator {
+	return func() float32 {
+		return gain * f()
+	}
+}
+
+func abs32(v float32) float32 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func floor32(v float32) float32 {
+	return float32(math.Floor(float64(v)))
+}
+
+func tanh32(v float32) float32 {
+	return float32(math.Tanh(float64(v)))
+}
+
+// GenOscillator returns an Oscillator producing the given Waveform at freq Hz.
+func GenOscillator(freq float32, w Waveform) Oscillator {
+	dt := 1.0 / float32(SampleRate)
+	k := 2.0 * Pi * freq
+	T := 1.0 / freq
+	t := float32(0.0)
+	return func() float32 {
+		var res float32
+		switch w {
+		case Square:
+			if f32.Sin(k*t) >= 0 {
+				res = 1.0
+			} else {
+				res = -1.0
+			}
+		case Triangle:
+			phase := t/T - floor32(t/T+0.5)
+			res = 4.0*abs32(phase) - 1.0
+		case Saw:
+			phase := t/T - floor32(t/T+0.5)
+			res = 2.0 * phase
+		case Noise:
+			res = 2.0*noiseSample() - 1.0
+		default: // Sine
+			res = f32.Sin(k * t)
+		}
+		t += dt
+		if t > T {
+			t -= T
+		}
+		return res
+	}
+}
+
+// noiseSample is a tiny xorshift PRNG kept local so Noise doesn't need
+// math/rand seeding at package init.
+var noiseState uint32 = 0x1234567
+
+func noiseSample() float32 {
+	noiseState ^= noiseState << 13
+	noiseState ^= noiseState >> 17
+	noiseState ^= noiseState << 5
+	return float32(noiseState%1000) / 1000.0
+}
+
+func Multiplex(fs ...Oscillator) Oscillator {
+	return func() float32 {
+		res := float32(0)
+		for _, osc := range fs {
+			res += osc()
+		}
+		return res
+	}
+}
+
+// GenEnvelope shapes f with an ADSR envelope driven by *press.
+func GenEnvelope(press *bool, f Oscillator, a ADSR) Oscillator {
+	dt := 1.0 / float32(SampleRate)
+	top := false
+	gain := float32(0.0)
+	attackd := dt / float32(a.Attack.Seconds())
+	dekeyd := dt / float32(a.Decay.Seconds())
+	sustainlevel := a.Sustain
+	sustaind := dt / 7.0
+	released := dt / float32(a.Release.Seconds())
+	return func() float32 {
+		if *press {
+			if !top {
+				gain += attackd
+				if gain > 1.0 {
+					top = true
+					gain = 1.0
+				}
+			} else {
+				if gain > sustainlevel {
+					gain -= dekeyd
+				} else {
+					gain -= sustaind
+				}
+				if gain < 0.0 {
+					gain = 0.0
+				}
+			}
+		} else {
+			top = false
+			gain -= released
+			if gain < 0.0 {
+				gain = 0.0
+			}
+		}
+		return gain * f()
+	}
+}
+
+// NewPiano builds a piano covering MIDI notes [loNote, hiNote] tuned by et,
+// with the given ADSR and Waveform applied by default to every key.
+func NewPiano(loNote, hiNote int, a ADSR, w Waveform, et EqualTemperament) *Piano {
+	p := new(Piano)
+	p.adsr = a
+	p.masterGain = 1.0
+	p.loNote = loNote
+
+	n := hiNote - loNote + 1
+	p.waveforms = make([]Waveform, n)
+	p.freqs = make([]float32, n)
+	for i := 0; i < n; i++ {
+		p.freqs[i] = et.Freq(loNote + i)
+		p.waveforms[i] = w
+	}
+
+	p.SetPolyphony(DefaultPolyphony)
+	return p
+}
+
+// SetPolyphony resizes the voice pool to n voices. Shrinking drops the
+// extra voices outright, cutting off whatever they were playing. n <= 0
+// leaves the pool empty, which makes NoteOn a no-op until it's grown again.
+func (p *Piano) SetPolyphony(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if n < 0 {
+		n = 0
+	}
+	voices := make([]*voice, n)
+	for i := range voices {
+		if i < len(p.voices) {
+			voices[i] = p.voices[i]
+			continue
+		}
+		voices[i] = &voice{key: -1}
+	}
+	p.voices = voices
+}
+
+// SetMasterGain sets the gain applied after mixing and before the soft
+// limiter.
+func (p *Piano) SetMasterGain(g float32) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.masterGain = g
+}
+
+// SetWaveform changes the waveform key will use the next time it's
+// triggered; voices already sounding on key keep playing their old chain.
+func (p *Piano) SetWaveform(key int, w Waveform) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.waveforms[key] = w
+}
+
+// SetADSR changes the envelope applied to notes triggered from now on.
+func (p *Piano) SetADSR(a ADSR) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.adsr = a
+}
+
+// buildChain builds a fresh oscillator+envelope chain for freq/w, matching
+// the harmonic stack the piano has always used, and returns it along with
+// the press flag that drives its envelope.
+func (p *Piano) buildChain(freq float32, w Waveform) (Oscillator, *bool) {
+	base := []Oscillator{}
+	for j := float32(1.0); j <= 8; j++ {
+		base = append(base, G(0.5/j, GenOscillator(freq*j, w)))
+	}
+	base = append(base, G(0.3, GenOscillator(freq+2, w)))
+	osc := Multiplex(base...)
+
+	press := new(bool)
+	env := G(0.4, GenEnvelope(press, osc, p.adsr))
+	return env, press
+}
+
+// allocVoice returns a voice to use for a new note: a never-used voice if one
+// exists, otherwise the released voice that was triggered earliest, and
+// failing that (every voice is still held down) the oldest voice overall.
+func (p *Piano) allocVoice() *voice {
+	for _, v := range p.voices {
+		if v.key == -1 {
+			return v
+		}
+	}
+
+	var oldestReleased *voice
+	for _, v := range p.voices {
+		if !*v.press && (oldestReleased == nil || v.gen < oldestReleased.gen) {
+			oldestReleased = v
+		}
+	}
+	if oldestReleased != nil {
+		return oldestReleased
+	}
+
+	oldest := p.voices[0]
+	for _, v := range p.voices[1:] {
+		if v.gen < oldest.gen {
+			oldest = v
+		}
+	}
+	return oldest
+}
+
+// NoteOn allocates a voice for key (stealing one if the pool is full) and
+// triggers it, so pressing the same key again — or any key while others
+// are held — starts a new, independently-releasing note instead of being a
+// no-op. It does nothing if SetPolyphony has shrunk the pool to empty.
+func (p *Piano) NoteOn(key int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.voices) == 0 {
+		return
+	}
+
+	p.gen++
+	v := p.allocVoice()
+	env, press := p.buildChain(p.freqs[key], p.waveforms[key])
+	*press = true
+	v.key = key
+	v.gen = p.gen
+	v.env = env
+	v.press = press
+}
+
+// NoteOff releases every voice currently sounding key.
+func (p *Piano) NoteOff(key int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, v := range p.voices {
+		if v.key == key && v.press != nil && *v.press {
+			*v.press = false
+		}
+	}
+}
+
+// Key maps a MIDI note number onto this piano's key range, so a live MIDI
+// controller or a decoded Standard MIDI File can drive NoteOn/NoteOff
+// without knowing the range the piano was built with.
+func (p *Piano) Key(midiNote int) (key int, ok bool) {
+	key = midiNote - p.loNote
+	if key < 0 || key >= len(p.freqs) {
+		return 0, false
+	}
+	return key, true
+}
+
+// GetOscillator returns the piano's master output: every voice's envelope
+// summed, scaled by masterGain and divided by sqrt(polyphony), then soft-clipped
+// with tanh so overlapping notes never wrap around int16 on the way out.
+func (p *Piano) GetOscillator() Oscillator {
+	return func() float32 {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		sum := float32(0)
+		for _, v := range p.voices {
+			if v.env != nil {
+				sum += v.env()
+			}
+		}
+		norm := p.masterGain / float32(math.Sqrt(float64(len(p.voices))))
+		return tanh32(sum * norm)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// noteEvent is a scheduled NoteOn/NoteOff for a single key, expressed as an
+// offset from the Sequencer's start time.
+type noteEvent struct {
+	key int
+	at  time.Duration
+	on  bool
+}
+
+// Sequencer drives a Piano from a clock ticked in lockstep with the audio
+// samples it produces, instead of time.Sleep in the caller. Because events
+// are just timestamps on a shared clock, notes can overlap freely.
+type Sequencer struct {
+	piano  *Piano
+	ctx    *Context
+	bpm    float64
+	events []noteEvent
+}
+
+// NewSequencer builds a Sequencer that plays piano through ctx. ctx may be
+// nil if the caller only intends to use EventOscillator (e.g. to render the
+// schedule to a file instead of a live device).
+func NewSequencer(piano *Piano, ctx *Context) *Sequencer {
+	return &Sequencer{piano: piano, ctx: ctx, bpm: 120}
+}
+
+// SetTempo sets the tempo used to interpret Pattern step durations.
+func (s *Sequencer) SetTempo(bpm float64) {
+	s.bpm = bpm
+}
+
+// ScheduleNoteOn schedules a NoteOn(key) at offset "at" from Play's start.
+func (s *Sequencer) ScheduleNoteOn(key int, at time.Duration) {
+	s.events = append(s.events, noteEvent{key: key, at: at, on: true})
+}
+
+// ScheduleNoteOff schedules a NoteOff(key) at offset "at" from Play's start.
+func (s *Sequencer) ScheduleNoteOff(key int, at time.Duration) {
+	s.events = append(s.events, noteEvent{key: key, at: at, on: false})
+}
+
+// stepDuration is the wall-clock length of one Pattern step at the current
+// tempo, treating a step as a sixteenth note.
+func (s *Sequencer) stepDuration() time.Duration {
+	beat := time.Duration(float64(time.Minute) / s.bpm)
+	return beat / 4
+}
+
+// Schedule expands a Pattern into ScheduleNoteOn/ScheduleNoteOff calls. A run
+// of consecutive cells holding the same key becomes a single note spanning
+// the whole run, so a held tone doesn't retrigger its envelope every step.
+func (s *Sequencer) Schedule(p Pattern) {
+	step := s.stepDuration()
+	for _, track := range p.Tracks {
+		i := 0
+		for i < len(track) {
+			key := track[i]
+			if key < 0 {
+				i++
+				continue // rest
+			}
+			start := i
+			for i < len(track) && track[i] == key {
+				i++
+			}
+			s.ScheduleNoteOn(key, time.Duration(start)*step)
+			s.ScheduleNoteOff(key, time.Duration(i)*step)
+		}
+	}
+}
+
+// Play runs the audio callback and fires every scheduled event against the
+// elapsed time since Play started, ticking once per audio chunk rather than
+// sleeping per note. It blocks until every event has fired or ctx is
+// cancelled.
+func (s *Sequencer) Play(ctx context.Context) {
+	sort.SliceStable(s.events, func(i, j int) bool { return s.events[i].at < s.events[j].at })
+
+	const chunk = 2048 / 2 // samples per Context.Play(1) call
+	tick := time.Second * chunk / SampleRate
+
+	var elapsed time.Duration
+	i := 0
+	for i < len(s.events) {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		s.ctx.Play(1)
+		for i < len(s.events) && s.events[i].at <= elapsed {
+			e := s.events[i]
+			if e.on {
+				s.piano.NoteOn(e.key)
+			} else {
+				s.piano.NoteOff(e.key)
+			}
+			i++
+		}
+		elapsed += tick
+	}
+}
+
+// EventOscillator returns an Oscillator that, on every call, fires whatever
+// events are due at the current sample, advances the sequencer's clock by one
+// sample, and then samples the piano — so a Renderer can bounce the whole schedule to a file
+// without a live Play loop driving NoteOn/NoteOff for it.
+func (s *Sequencer) EventOscillator() Oscillator {
+	sort.SliceStable(s.events, func(i, j int) bool { return s.events[i].at < s.events[j].at })
+
+	osc := s.piano.GetOscillator()
+	tick := time.Second / SampleRate
+	var elapsed time.Duration
+	i := 0
+	return func() float32 {
+		for i < len(s.events) && s.events[i].at <= elapsed {
+			e := s.events[i]
+			if e.on {
+				s.piano.NoteOn(e.key)
+			} else {
+				s.piano.NoteOff(e.key)
+			}
+			i++
+		}
+		elapsed += tick
+		return osc()
+	}
+}
+
+// Pattern is a tracks x steps grid describing which Piano key each track
+// plays on each step (-1 for a rest), so a melody can be expressed as data
+// instead of imperative NoteOn/NoteOff calls.
+type Pattern struct {
+	Tracks [][]int
+}
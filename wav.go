@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// Renderer bounces an Oscillator to PCM/WAV instead of a live device, so
+// synthesis can be exercised (and its output diffed) without a sound card.
+type Renderer struct {
+	oscillator Oscillator
+}
+
+// NewRenderer wraps oscillator for offline rendering.
+func NewRenderer(oscillator Oscillator) *Renderer {
+	return &Renderer{oscillator: oscillator}
+}
+
+// Render writes d worth of audio pulled from the oscillator to w as a mono
+// 16-bit PCM WAV file at SampleRate.
+func (r *Renderer) Render(w io.Writer, d time.Duration) error {
+	numSamples := int(d.Seconds() * float64(SampleRate))
+	dataSize := numSamples * 2 // 16-bit mono
+
+	if err := writeWAVHeader(w, dataSize); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 2)
+	for i := 0; i < numSamples; i++ {
+		v := int16(float32(32767) * r.oscillator())
+		binary.LittleEndian.PutUint16(buf, uint16(v))
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeWAVHeader writes a 44-byte canonical RIFF/WAVE header for mono
+// 16-bit PCM at SampleRate, describing a data chunk of dataSize bytes.
+func writeWAVHeader(w io.Writer, dataSize int) error {
+	const (
+		channels      = 1
+		bitsPerSample = 16
+	)
+	byteRate := SampleRate * channels * bitsPerSample / 8
+	blockAlign := channels * bitsPerSample / 8
+
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+dataSize))
+	buf.WriteString("WAVE")
+	buf.WriteString("fmt ")
+	binary.Write(&buf, binary.LittleEndian, uint32(16)) // fmt chunk size
+	binary.Write(&buf, binary.LittleEndian, uint16(1))  // PCM
+	binary.Write(&buf, binary.LittleEndian, uint16(channels))
+	binary.Write(&buf, binary.LittleEndian, uint32(SampleRate))
+	binary.Write(&buf, binary.LittleEndian, uint32(byteRate))
+	binary.Write(&buf, binary.LittleEndian, uint16(blockAlign))
+	binary.Write(&buf, binary.LittleEndian, uint16(bitsPerSample))
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(dataSize))
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// TestRenderWritesHeaderAndSamples pulls a few samples from a fixed
+// oscillator and checks the WAV header describes them correctly and the PCM
+// payload matches what the oscillator produced, in order.
+func TestRenderWritesHeaderAndSamples(t *testing.T) {
+	values := []float32{0.5, -0.5, 1, -1}
+	i := 0
+	osc := func() float32 {
+		v := values[i%len(values)]
+		i++
+		return v
+	}
+
+	d := time.Duration(len(values)) * time.Second / SampleRate
+	var buf bytes.Buffer
+	if err := NewRenderer(osc).Render(&buf, d); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+
+	const headerSize = 44
+	dataSize := len(values) * 2
+	if got, want := buf.Len(), headerSize+dataSize; got != want {
+		t.Fatalf("buf.Len() = %d, want %d", got, want)
+	}
+
+	header := buf.Bytes()[:headerSize]
+	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+		t.Fatalf("header = %q, want RIFF/WAVE chunk IDs", header[:12])
+	}
+	if got := binary.LittleEndian.Uint32(header[4:8]); got != uint32(36+dataSize) {
+		t.Errorf("RIFF chunk size = %d, want %d", got, 36+dataSize)
+	}
+	if got := binary.LittleEndian.Uint16(header[22:24]); got != 1 {
+		t.Errorf("channel count = %d, want 1 (mono)", got)
+	}
+	if got := binary.LittleEndian.Uint32(header[24:28]); got != uint32(SampleRate) {
+		t.Errorf("sample rate = %d, want %d", got, SampleRate)
+	}
+	if got := binary.LittleEndian.Uint16(header[34:36]); got != 16 {
+		t.Errorf("bits per sample = %d, want 16", got)
+	}
+	if got := binary.LittleEndian.Uint32(header[40:44]); got != uint32(dataSize) {
+		t.Errorf("data chunk size = %d, want %d", got, dataSize)
+	}
+
+	data := buf.Bytes()[headerSize:]
+	for n, want := range values {
+		got := int16(binary.LittleEndian.Uint16(data[n*2 : n*2+2]))
+		if wantSample := int16(float32(32767) * want); got != wantSample {
+			t.Errorf("sample %d = %d, want %d", n, got, wantSample)
+		}
+	}
+}